@@ -0,0 +1,76 @@
+// Package auth provides pluggable identity providers for multipass.
+//
+// A Provider wraps an OAuth2/OIDC flow: it builds the authorization URL,
+// exchanges the callback code for tokens and resolves the verified
+// identity (email/subject) that the token was issued to. Multipass binds
+// that identity into its own JWT the same way it does for the email
+// magic-link flow, so federated login is just another way to decide
+// "this handle is who it claims to be".
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ErrNoIdentity is returned by a Provider when a callback cannot be
+// resolved to a usable identity (missing/unverified email, bad state, …).
+var ErrNoIdentity = errors.New("auth: no identity in callback")
+
+// Identity is the result of a completed provider login.
+type Identity struct {
+	// Provider is the name the identity was authenticated against, e.g. "google".
+	Provider string
+	// Subject is the provider's stable identifier for the account.
+	Subject string
+	// Email is the verified email claimed by the account, used as the
+	// multipass handle.
+	Email string
+}
+
+// Authenticator is implemented by the redirect-based (OIDC/OAuth2)
+// providers, which turn the request that ends such a login into a
+// verified Identity. The email magic-link flow does not go through it.
+type Authenticator interface {
+	// Name identifies the provider, e.g. "google", "github", "oidc".
+	Name() string
+
+	// Redirect returns the URL the user should be sent to in order to
+	// start a login with this provider. state is an opaque value the
+	// provider must echo back on the callback so callers can bind the
+	// callback to the request that started it.
+	Redirect(state string) string
+
+	// Callback completes the flow from the redirect-back request and
+	// returns the verified Identity.
+	Callback(ctx context.Context, r *http.Request) (*Identity, error)
+}
+
+// Registry holds the set of configured providers, keyed by name, so
+// ServeHTTP can dispatch "/login/<provider>" and "/callback/<provider>"
+// without knowing about concrete provider types.
+type Registry struct {
+	providers map[string]Authenticator
+}
+
+// NewRegistry returns an empty Registry ready to be populated with Add.
+func NewRegistry() *Registry {
+	return &Registry{providers: map[string]Authenticator{}}
+}
+
+// Add registers a provider, replacing any existing provider with the same name.
+func (reg *Registry) Add(a Authenticator) {
+	reg.providers[a.Name()] = a
+}
+
+// Get returns the provider registered under name, if any.
+func (reg *Registry) Get(name string) (Authenticator, bool) {
+	a, ok := reg.providers[name]
+	return a, ok
+}
+
+// Len reports how many providers are registered.
+func (reg *Registry) Len() int {
+	return len(reg.providers)
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	githubAuthURL  = "https://github.com/login/oauth/authorize"
+	githubTokenURL = "https://github.com/login/oauth/access_token"
+	githubEmailURL = "https://api.github.com/user/emails"
+)
+
+// GithubConfig configures the GitHub OAuth2 provider.
+type GithubConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Org, if set, restricts accepted accounts to members of this GitHub org.
+	Org string
+}
+
+// GithubProvider authenticates against GitHub's OAuth2 API. Unlike the
+// generic OIDCProvider it has no ID token or userinfo endpoint, so it
+// resolves the verified, primary email via the REST API instead.
+type GithubProvider struct {
+	oauth2 *oauth2.Config
+	org    string
+}
+
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// NewGithub returns an Authenticator for GitHub.
+func NewGithub(cfg GithubConfig) Authenticator {
+	return &GithubProvider{
+		oauth2: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       []string{"user:email", "read:org"},
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  githubAuthURL,
+				TokenURL: githubTokenURL,
+			},
+		},
+		org: cfg.Org,
+	}
+}
+
+// Name implements Authenticator.
+func (p *GithubProvider) Name() string { return "github" }
+
+// Redirect implements Authenticator.
+func (p *GithubProvider) Redirect(state string) string {
+	return p.oauth2.AuthCodeURL(state, oauth2.AccessTypeOnline)
+}
+
+// Callback implements Authenticator.
+func (p *GithubProvider) Callback(ctx context.Context, r *http.Request) (*Identity, error) {
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		return nil, fmt.Errorf("auth: github returned error %q", errParam)
+	}
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		return nil, errors.New("auth: callback missing code parameter")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	tok, err := p.oauth2.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("auth: exchanging code: %w", err)
+	}
+
+	client := p.oauth2.Client(ctx, tok)
+	if p.org != "" {
+		if ok, err := p.isOrgMember(client, p.org); err != nil || !ok {
+			if err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("auth: account is not a member of %s", p.org)
+		}
+	}
+
+	email, err := p.primaryEmail(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{Provider: "github", Email: email}, nil
+}
+
+func (p *GithubProvider) primaryEmail(client *http.Client) (string, error) {
+	resp, err := client.Get(githubEmailURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("auth: github /user/emails returned %s", resp.Status)
+	}
+	var emails []githubEmail
+	if err := json.NewDecoder(resp.Body).Decode(&emails); err != nil {
+		return "", err
+	}
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			return e.Email, nil
+		}
+	}
+	return "", ErrNoIdentity
+}
+
+func (p *GithubProvider) isOrgMember(client *http.Client, org string) (bool, error) {
+	resp, err := client.Get(fmt.Sprintf("https://api.github.com/user/memberships/orgs/%s", org))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK, nil
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const googleIssuer = "https://accounts.google.com"
+
+// GoogleConfig configures the Google preset of OIDCProvider.
+type GoogleConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Domain, if set, restricts accepted accounts to this G Suite domain.
+	Domain string
+}
+
+// NewGoogle returns an Authenticator for Google's OIDC provider, discovered
+// from its well-known issuer document.
+func NewGoogle(ctx context.Context, cfg GoogleConfig) (Authenticator, error) {
+	p, err := NewOIDC(ctx, OIDCConfig{
+		Name:         "google",
+		Issuer:       googleIssuer,
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Domain == "" {
+		return p, nil
+	}
+	return &domainRestricted{OIDCProvider: p, domain: cfg.Domain}, nil
+}
+
+// domainRestricted wraps an OIDCProvider and rejects identities outside a
+// configured email domain, e.g. a G Suite org.
+type domainRestricted struct {
+	*OIDCProvider
+	domain string
+}
+
+// Callback overrides OIDCProvider.Callback to enforce the domain restriction
+// after the underlying provider has resolved and verified the identity.
+func (d *domainRestricted) Callback(ctx context.Context, r *http.Request) (*Identity, error) {
+	id, err := d.OIDCProvider.Callback(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(id.Email, "@"+d.domain) {
+		return nil, fmt.Errorf("auth: %s is not in domain %s", id.Email, d.domain)
+	}
+	return id, nil
+}
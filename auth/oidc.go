@@ -0,0 +1,290 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+
+	"golang.org/x/oauth2"
+)
+
+// discoveryDoc is the subset of a provider's
+// /.well-known/openid-configuration document that OIDC needs.
+type discoveryDoc struct {
+	Issuer      string `json:"issuer"`
+	AuthURL     string `json:"authorization_endpoint"`
+	TokenURL    string `json:"token_endpoint"`
+	UserInfoURL string `json:"userinfo_endpoint"`
+	JWKSURL     string `json:"jwks_uri"`
+}
+
+// OIDCConfig configures a generic OIDC provider discovered from an issuer's
+// well-known document. Google is a thin preset built on top of it; GitHub,
+// which has no OIDC support, has its own GithubProvider.
+type OIDCConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	// AuthURL/TokenURL/UserInfoURL/JWKSURL let callers skip discovery for
+	// providers that don't publish a discovery document.
+	AuthURL, TokenURL, UserInfoURL, JWKSURL string
+}
+
+// OIDCProvider authenticates against an OpenID Connect issuer using the
+// authorization code flow, verifying the returned ID token's signature,
+// issuer and audience before trusting its claims.
+type OIDCProvider struct {
+	name        string
+	issuer      string
+	oauth2      *oauth2.Config
+	jwksURL     string
+	userinfoURL string
+}
+
+type idClaims struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// idTokenClaims is the subset of an ID token's payload Callback checks
+// before trusting idClaims extracted from it.
+type idTokenClaims struct {
+	idClaims
+	Issuer   string   `json:"iss"`
+	Audience audience `json:"aud"`
+	Expiry   int64    `json:"exp"`
+}
+
+// audience accepts the "aud" claim in either form the JWT spec allows: a
+// single string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multi []string
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return err
+	}
+	*a = audience(multi)
+	return nil
+}
+
+func (a audience) has(v string) bool {
+	for _, s := range a {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// NewOIDC builds a Provider for cfg, fetching the issuer's discovery
+// document when AuthURL/TokenURL aren't supplied directly.
+func NewOIDC(ctx context.Context, cfg OIDCConfig) (*OIDCProvider, error) {
+	issuer := cfg.Issuer
+	authURL, tokenURL, userinfoURL, jwksURL := cfg.AuthURL, cfg.TokenURL, cfg.UserInfoURL, cfg.JWKSURL
+	if authURL == "" || tokenURL == "" {
+		doc, err := discover(ctx, cfg.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("auth: discovering %s: %w", cfg.Issuer, err)
+		}
+		issuer = doc.Issuer
+		authURL, tokenURL, userinfoURL, jwksURL = doc.AuthURL, doc.TokenURL, doc.UserInfoURL, doc.JWKSURL
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email"}
+	}
+
+	p := &OIDCProvider{
+		name:   cfg.Name,
+		issuer: issuer,
+		oauth2: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       scopes,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  authURL,
+				TokenURL: tokenURL,
+			},
+		},
+		jwksURL:     jwksURL,
+		userinfoURL: userinfoURL,
+	}
+	return p, nil
+}
+
+func discover(ctx context.Context, issuer string) (*discoveryDoc, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery returned %s", resp.Status)
+	}
+	doc := &discoveryDoc{}
+	if err := json.NewDecoder(resp.Body).Decode(doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func fetchJWKS(ctx context.Context, jwksURL string) (*jose.JSONWebKeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks endpoint returned %s", resp.Status)
+	}
+	set := &jose.JSONWebKeySet{}
+	if err := json.NewDecoder(resp.Body).Decode(set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// Name implements Authenticator.
+func (p *OIDCProvider) Name() string { return p.name }
+
+// Redirect implements Authenticator.
+func (p *OIDCProvider) Redirect(state string) string {
+	return p.oauth2.AuthCodeURL(state, oauth2.AccessTypeOnline)
+}
+
+// Callback implements Authenticator. It exchanges the code, verifies the ID
+// token the provider returned, and resolves the account's email from its
+// claims, falling back to the userinfo endpoint for providers that don't
+// put email in the ID token.
+func (p *OIDCProvider) Callback(ctx context.Context, r *http.Request) (*Identity, error) {
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		return nil, fmt.Errorf("auth: %s returned error %q", p.name, errParam)
+	}
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		return nil, errors.New("auth: callback missing code parameter")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	tok, err := p.oauth2.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("auth: exchanging code: %w", err)
+	}
+
+	rawIDToken, ok := tok.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("auth: token response missing id_token")
+	}
+	claims, err := p.verifyIDToken(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("auth: verifying ID token: %w", err)
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		claims, err = p.fetchUserinfo(ctx, tok)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, ErrNoIdentity
+	}
+
+	return &Identity{
+		Provider: p.name,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}, nil
+}
+
+// verifyIDToken checks rawIDToken's signature against the issuer's JWKS,
+// and its iss/exp/aud claims, before idClaims inside it can be trusted.
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, rawIDToken string) (*idClaims, error) {
+	if p.jwksURL == "" {
+		return nil, fmt.Errorf("%s has no jwks_uri configured, cannot verify ID tokens", p.name)
+	}
+	obj, err := jose.ParseSigned(rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("parsing ID token: %w", err)
+	}
+	if len(obj.Signatures) == 0 {
+		return nil, errors.New("ID token has no signature")
+	}
+
+	set, err := fetchJWKS(ctx, p.jwksURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetching jwks: %w", err)
+	}
+	keys := set.Key(obj.Signatures[0].Header.KeyID)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("ID token signed by unknown key %q", obj.Signatures[0].Header.KeyID)
+	}
+
+	payload, err := obj.Verify(keys[0].Key)
+	if err != nil {
+		return nil, fmt.Errorf("verifying signature: %w", err)
+	}
+
+	var claims idTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, err
+	}
+	if claims.Issuer != p.issuer {
+		return nil, fmt.Errorf("issuer %q does not match expected %q", claims.Issuer, p.issuer)
+	}
+	if !claims.Audience.has(p.oauth2.ClientID) {
+		return nil, fmt.Errorf("audience %v does not include client %q", claims.Audience, p.oauth2.ClientID)
+	}
+	if time.Unix(claims.Expiry, 0).Before(time.Now()) {
+		return nil, errors.New("ID token expired")
+	}
+	return &claims.idClaims, nil
+}
+
+func (p *OIDCProvider) fetchUserinfo(ctx context.Context, tok *oauth2.Token) (*idClaims, error) {
+	if p.userinfoURL == "" {
+		return nil, fmt.Errorf("auth: %s has no userinfo endpoint configured", p.name)
+	}
+	client := p.oauth2.Client(ctx, tok)
+	resp, err := client.Get(p.userinfoURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth: userinfo returned %s", resp.Status)
+	}
+	claims := &idClaims{}
+	if err := json.NewDecoder(resp.Body).Decode(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
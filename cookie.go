@@ -0,0 +1,84 @@
+package multipass
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dbnegative/multipass/session"
+)
+
+// cookieName is the base name of the session cookie. Values too large for a
+// single cookie are split into numbered chunks (jwt_token_0, jwt_token_1, …)
+// by session.Split.
+const cookieName = "jwt_token"
+
+// setSessionCookie seals tokenStr and sets it as the session cookie,
+// chunked if necessary. The seal's own timestamp-based TTL tracks m.Expires
+// so a stolen cookie can't outlive the JWT it carries even if its exp claim
+// were somehow altered.
+func (m *Multipass) setSessionCookie(w http.ResponseWriter, tokenStr string) error {
+	sealed, err := m.cookieCipher.Seal(cookieName, []byte(tokenStr), time.Now())
+	if err != nil {
+		return err
+	}
+	for _, chunk := range session.Split(cookieName, sealed) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     chunk.Name,
+			Value:    chunk.Value,
+			Path:     "/",
+			Domain:   m.cookieDomain,
+			Secure:   m.cookieSecure,
+			HttpOnly: true,
+			SameSite: m.cookieSameSite,
+			MaxAge:   int(m.Expires.Seconds()),
+		})
+	}
+	return nil
+}
+
+// clearSessionCookie expires every chunk of the session cookie.
+func (m *Multipass) clearSessionCookie(w http.ResponseWriter, r *http.Request) {
+	names := []string{cookieName}
+	for i := 0; ; i++ {
+		name := fmt.Sprintf("%s_%d", cookieName, i)
+		if _, err := r.Cookie(name); err != nil {
+			break
+		}
+		names = append(names, name)
+	}
+	for _, name := range names {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			Domain:   m.cookieDomain,
+			Secure:   m.cookieSecure,
+			HttpOnly: true,
+			SameSite: m.cookieSameSite,
+			Expires:  time.Now().AddDate(-1, 0, 0),
+			MaxAge:   -1,
+		})
+	}
+}
+
+// sessionToken reads, reassembles and opens the session cookie, returning
+// the JWT it carries.
+func (m *Multipass) sessionToken(r *http.Request) (string, error) {
+	sealed, ok := session.Join(cookieName, func(name string) (string, bool) {
+		c, err := r.Cookie(name)
+		if err != nil {
+			return "", false
+		}
+		return c.Value, true
+	})
+	if !ok {
+		return "", errors.New("no session cookie found")
+	}
+	tokenStr, err := m.cookieCipher.Open(cookieName, sealed, m.Expires, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return string(tokenStr), nil
+}
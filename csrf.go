@@ -0,0 +1,31 @@
+package multipass
+
+import (
+	"net/http"
+
+	"github.com/gorilla/csrf"
+)
+
+// withCSRF wraps h with gorilla/csrf's double-submit-cookie protection, so
+// POSTs to the login form are rejected unless they carry the token the GET
+// that rendered the form embedded. h can call csrf.TemplateField(r) to get
+// that hidden field.
+func (a *Auth) withCSRF(h handlerFunc) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		var status int
+		var herr error
+		protect := csrf.Protect(
+			a.csrfKey,
+			csrf.Secure(a.cookieSecure),
+			csrf.Path(a.Basepath),
+			csrf.ErrorHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status, herr = a.renderError(w, http.StatusForbidden,
+					a.tmpl.T("Your form session has expired. Please reload the page and try again."))
+			})),
+		)
+		protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			status, herr = h(w, r)
+		})).ServeHTTP(w, r)
+		return status, herr
+	}
+}
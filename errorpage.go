@@ -0,0 +1,17 @@
+package multipass
+
+import "net/http"
+
+// renderError writes the themed error.html page with message and returns
+// status for Caddy's logging. The error is nil unless rendering the
+// template fails, since the body has already been written here rather
+// than left for Caddy's own default error page.
+func (a *Auth) renderError(w http.ResponseWriter, status int, message string) (int, error) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	data := struct{ Message string }{Message: message}
+	if err := a.tmpl.Execute(w, "error.html", data); err != nil {
+		return status, err
+	}
+	return status, nil
+}
@@ -0,0 +1,142 @@
+package multipass
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// fakeSender records the handle/url pairs it was asked to send instead of
+// talking to an SMTP server, so login can be tested without real mail.
+type fakeSender struct {
+	handle, url string
+}
+
+func (f *fakeSender) Send(handle, loginURL string) error {
+	f.handle = handle
+	f.url = loginURL
+	return nil
+}
+
+func newTestAuth(t *testing.T) *Auth {
+	t.Helper()
+	m, err := NewMultipassFromRule(Rule{
+		Basepath:      "/",
+		Handles:       []string{"user@example.com"},
+		SiteAddr:      "https://example.com",
+		CookieSecrets: []string{"test-secret"},
+	})
+	if err != nil {
+		t.Fatalf("NewMultipassFromRule: %v", err)
+	}
+	return &Auth{Multipass: m}
+}
+
+func TestAuthLogin(t *testing.T) {
+	a := newTestAuth(t)
+	sender := &fakeSender{}
+	a.sender = sender
+
+	form := url.Values{"handle": {"user@example.com"}}
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.PostForm = form
+	w := httptest.NewRecorder()
+
+	code, err := a.login(w, req)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if code != http.StatusOK {
+		t.Fatalf("login status = %d, want %d", code, http.StatusOK)
+	}
+	if sender.handle != "user@example.com" {
+		t.Fatalf("sender.handle = %q, want %q", sender.handle, "user@example.com")
+	}
+}
+
+func TestAuthToken(t *testing.T) {
+	a := newTestAuth(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	code, err := a.token(w, req)
+	if err == nil {
+		t.Fatal("token: expected error for request with no session token")
+	}
+	if code != http.StatusUnauthorized {
+		t.Fatalf("token status = %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	tokenStr, err := a.AccessToken("user@example.com")
+	if err != nil {
+		t.Fatalf("AccessToken: %v", err)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenStr)
+	w = httptest.NewRecorder()
+
+	code, err = a.token(w, req)
+	if err != nil {
+		t.Fatalf("token: %v", err)
+	}
+	if code != http.StatusOK {
+		t.Fatalf("token status = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestAuthSignout(t *testing.T) {
+	a := newTestAuth(t)
+
+	tokenStr, err := a.AccessToken("user@example.com")
+	if err != nil {
+		t.Fatalf("AccessToken: %v", err)
+	}
+	if err := a.setSessionCookie(httptest.NewRecorder(), tokenStr); err != nil {
+		t.Fatalf("setSessionCookie: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/signout", nil)
+	sealW := httptest.NewRecorder()
+	if err := a.setSessionCookie(sealW, tokenStr); err != nil {
+		t.Fatalf("setSessionCookie: %v", err)
+	}
+	for _, c := range sealW.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+
+	code, err := a.signout(w, req)
+	if err != nil {
+		t.Fatalf("signout: %v", err)
+	}
+	if code != http.StatusOK {
+		t.Fatalf("signout status = %d, want %d", code, http.StatusOK)
+	}
+
+	if _, err := a.token(httptest.NewRecorder(), req); err == nil {
+		t.Fatal("token succeeded for a session revoked by signout")
+	}
+}
+
+func TestAuthJWKS(t *testing.T) {
+	a := newTestAuth(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+
+	code, err := a.jwks(w, req)
+	if err != nil {
+		t.Fatalf("jwks: %v", err)
+	}
+	if code != http.StatusOK {
+		t.Fatalf("jwks status = %d, want %d", code, http.StatusOK)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type = %q, want application/json", ct)
+	}
+	if w.Body.Len() == 0 {
+		t.Fatal("jwks returned an empty body")
+	}
+}
@@ -0,0 +1,46 @@
+package multipass
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+)
+
+// jwk is a single entry of a JSON Web Key Set, RFC 7517.
+type jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// jwks serves the RSA signing keys Multipass currently accepts, so
+// downstream verifiers (e.g. services using dgrijalva/jwt-go) can resolve a
+// token's "kid" header to the right public key without operators shipping
+// keys out of band, and can keep verifying through a rotation.
+func (a *Auth) jwks(w http.ResponseWriter, r *http.Request) (int, error) {
+	set := jwkSet{}
+	for _, info := range a.Multipass.keys.All() {
+		set.Keys = append(set.Keys, jwk{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: "PS512",
+			Kid: info.Kid,
+			N:   base64.RawURLEncoding.EncodeToString(info.Key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(info.Key.E)).Bytes()),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(set); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,143 @@
+package multipass
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+	"sync"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// maxRetainedKeys bounds how many signing keys a KeyStore remembers, the
+// current key included, so a long-running rotation schedule doesn't grow the JWKS
+// document forever. Retired keys stop verifying once evicted, so this
+// should comfortably exceed a token's lifetime divided by the rotation
+// interval.
+const maxRetainedKeys = 3
+
+// signingKey is one RSA keypair usable for signing and verifying tokens,
+// identified by its kid.
+type signingKey struct {
+	kid    string
+	key    *rsa.PrivateKey
+	signer jose.Signer
+}
+
+// KeyStore holds the signing key Multipass currently mints tokens with,
+// plus however many previous keys Rotate has retained, so tokens signed
+// before a rotation keep verifying by kid until they're evicted.
+type KeyStore struct {
+	mu   sync.RWMutex
+	keys []*signingKey // keys[0] is current; the rest verify only
+}
+
+// NewKeyStore generates an initial signing key.
+func NewKeyStore() (*KeyStore, error) {
+	ks := &KeyStore{}
+	if err := ks.Rotate(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Rotate generates a new signing key and makes it current, retaining up to
+// maxRetainedKeys-1 previous keys purely for verification.
+func (ks *KeyStore) Rotate() error {
+	pk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	kid := keyID(&pk.PublicKey)
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.PS512, Key: pk},
+		(&jose.SignerOptions{}).WithHeader("kid", kid),
+	)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys = append([]*signingKey{{kid: kid, key: pk, signer: signer}}, ks.keys...)
+	if len(ks.keys) > maxRetainedKeys {
+		ks.keys = ks.keys[:maxRetainedKeys]
+	}
+	return nil
+}
+
+// StartRotation rotates the store every interval until the returned stop
+// function is called.
+func (ks *KeyStore) StartRotation(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := ks.Rotate(); err != nil {
+					log.Printf("multipass: key rotation failed: %v", err)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// Current returns the kid and Signer that new tokens should be minted with.
+func (ks *KeyStore) Current() (kid string, signer jose.Signer) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	cur := ks.keys[0]
+	return cur.kid, cur.signer
+}
+
+// CurrentPublicKey returns the public half of the current signing key.
+func (ks *KeyStore) CurrentPublicKey() *rsa.PublicKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return &ks.keys[0].key.PublicKey
+}
+
+// PublicKey returns the verifying key for kid, if it's still retained.
+func (ks *KeyStore) PublicKey(kid string) (*rsa.PublicKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	for _, k := range ks.keys {
+		if k.kid == kid {
+			return &k.key.PublicKey, true
+		}
+	}
+	return nil, false
+}
+
+// PublicKeyInfo pairs a kid with the public key it identifies, for
+// publishing a JWKS document.
+type PublicKeyInfo struct {
+	Kid string
+	Key *rsa.PublicKey
+}
+
+// All returns every key currently retained, current key first.
+func (ks *KeyStore) All() []PublicKeyInfo {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	out := make([]PublicKeyInfo, len(ks.keys))
+	for i, k := range ks.keys {
+		out[i] = PublicKeyInfo{Kid: k.kid, Key: &k.key.PublicKey}
+	}
+	return out
+}
+
+// keyID derives a short, stable identifier for a public key so verifiers
+// can pick the right key out of a JWKS document without guessing.
+func keyID(pub *rsa.PublicKey) string {
+	sum := sha256.Sum256(pub.N.Bytes())
+	return base64.RawURLEncoding.EncodeToString(sum[:8])
+}
@@ -0,0 +1,128 @@
+package multipass
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/gorilla/csrf"
+)
+
+// LoginURL builds the link emailed to a user: u with its path set to the
+// login endpoint and tokenStr (a single-use login code) as its token query
+// parameter.
+func (m *Multipass) LoginURL(u url.URL, tokenStr string) url.URL {
+	u.Path = path.Join(m.Basepath, "login")
+	v := url.Values{}
+	v.Set("token", tokenStr)
+	u.RawQuery = v.Encode()
+
+	return u
+}
+
+// loginFormData renders login.html, shared by login's bare GET form and
+// loginform's redirect-back variant.
+type loginFormData struct {
+	FormAction string
+	CSRFField  template.HTML
+	ReturnURL  string
+}
+
+// login serves both the magic-link request form (POST, emails a single-use
+// code) and the link the user follows back (GET ?token=..., which consumes
+// that code and starts a session).
+func (a *Auth) login(w http.ResponseWriter, r *http.Request) (int, error) {
+	m := a.Multipass
+	if r.Method == "POST" {
+		r.ParseForm()
+		handle := r.PostForm.Get("handle")
+		if len(handle) == 0 {
+			loc := path.Join(m.Basepath, "login")
+			http.Redirect(w, r, loc, http.StatusSeeOther)
+			return http.StatusSeeOther, nil
+		}
+		switch m.authorizer.IsAuthorized(handle) {
+		case true:
+			code, err := randomState()
+			if err != nil {
+				log.Print(err)
+			}
+			state, err := issueState(w, r, m.Basepath)
+			if err != nil {
+				log.Print(err)
+			}
+			if err := m.store.Put(code, handle, state, time.Now().Add(m.loginCodeExpires)); err != nil {
+				log.Print(err)
+			}
+			if m.siteURL == nil {
+				log.Print("multipass: login requires site_addr to be configured")
+				return http.StatusInternalServerError, nil
+			}
+			loginURL := m.LoginURL(*m.siteURL, code)
+			if err := m.sender.Send(handle, loginURL.String()); err != nil {
+				log.Print(err)
+			}
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := m.tmpl.Execute(w, "sent.html", struct{ Handle string }{Handle: handle}); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		return http.StatusOK, nil
+	}
+	if r.Method == "GET" {
+		if code := r.URL.Query().Get("token"); len(code) > 0 {
+			handle, ok, err := m.store.Consume(code, requestState(r))
+			if err != nil {
+				return http.StatusInternalServerError, err
+			}
+			if !ok || !m.authorizer.IsAuthorized(handle) {
+				return a.renderError(w, http.StatusUnauthorized, m.tmpl.T(
+					"This login link is invalid, expired, already used, or was not opened in the browser it was requested from."))
+			}
+			tokenStr, err := m.AccessToken(handle)
+			if err != nil {
+				return http.StatusInternalServerError, err
+			}
+			if err := m.setSessionCookie(w, tokenStr); err != nil {
+				log.Print(err)
+				return http.StatusInternalServerError, err
+			}
+			r.URL.Path = ""
+			r.URL.RawQuery = ""
+			http.Redirect(w, r, r.URL.String(), http.StatusSeeOther)
+			return http.StatusSeeOther, nil
+		}
+		if _, err := issueState(w, r, m.Basepath); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		data := loginFormData{FormAction: r.URL.Path, CSRFField: csrf.TemplateField(r)}
+		if err := m.tmpl.Execute(w, "login.html", data); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		return http.StatusOK, nil
+	}
+	return http.StatusMethodNotAllowed, nil
+}
+
+// loginform renders the form shown when an unauthenticated request hits a
+// protected resource, pre-filling a hidden field with the URL to return to.
+func (a *Auth) loginform(w http.ResponseWriter, r *http.Request) (int, error) {
+	m := a.Multipass
+	if _, err := issueState(w, r, m.Basepath); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	data := loginFormData{
+		FormAction: path.Join(m.Basepath, "login"),
+		CSRFField:  csrf.TemplateField(r),
+		ReturnURL:  r.URL.String(),
+	}
+	if err := m.tmpl.Execute(w, "login.html", data); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
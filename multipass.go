@@ -1,30 +1,36 @@
 package multipass
 
 import (
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/json"
-	"encoding/pem"
+	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
-	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mholt/caddy/caddyhttp/httpserver"
 
-	jose "gopkg.in/square/go-jose.v1"
+	"github.com/dbnegative/multipass/auth"
+	"github.com/dbnegative/multipass/session"
+	"github.com/dbnegative/multipass/store"
+	"github.com/dbnegative/multipass/templates"
 )
 
 var ErrInvalidToken error = errors.New("invalid token")
 
+// Auth is the Caddy middleware handler: it guards Next with multipass's
+// login/signout/jwks/callback routes and the session-token check. Routes
+// are built once per Auth value, lazily, on first ServeHTTP call.
 type Auth struct {
 	*Multipass
 	Next httpserver.Handler
+
+	once sync.Once
+	mux  *mux
 }
 
 type Rule struct {
@@ -35,6 +41,61 @@ type Rule struct {
 
 	SMTPAddr, SMTPUser, SMTPPass string
 	MailFrom, MailTmpl           string
+
+	// SiteAddr is the externally reachable base URL multipass is served
+	// from, used to build provider redirect URLs.
+	SiteAddr string
+	// Providers configures federated OIDC/OAuth2 identity providers to
+	// offer alongside the email magic-link flow.
+	Providers []ProviderConfig
+
+	// CookieSecrets encrypts and signs the session cookie. The first
+	// secret signs and encrypts new cookies; any additional secrets are
+	// previous values kept only so cookies issued under them keep
+	// verifying until they age out, allowing secrets to be rotated
+	// without logging everyone out at once.
+	CookieSecrets []string
+	// CookieInsecure disables the cookie's Secure attribute. Only for
+	// local HTTP development; leave false in production.
+	CookieInsecure bool
+	// CookieSameSite is "Strict", "Lax" or "None". Defaults to "Lax".
+	CookieSameSite string
+	// CookieDomain sets the cookie's Domain attribute, if any.
+	CookieDomain string
+
+	// CSRFSecret authenticates the CSRF token issued for the login form.
+	// Defaults to deriving a key from the first CookieSecret so operators
+	// aren't forced to manage a second one.
+	CSRFSecret string
+
+	// KeyRotation, if set, rotates the RSA signing key on this interval
+	// so operators can roll keys without invalidating tokens already
+	// issued under the previous one.
+	KeyRotation time.Duration
+
+	// StoreBackend selects where single-use login codes and revoked jtis
+	// are kept: "memory" (default), "bolt" or "redis". "bolt" and "redis"
+	// let multipass run behind multiple Caddy instances sharing state.
+	StoreBackend string
+	// StorePath is the BoltDB file path when StoreBackend is "bolt".
+	StorePath string
+	// StoreAddr, StorePassword and StoreDB configure the Redis client
+	// when StoreBackend is "redis".
+	StoreAddr     string
+	StorePassword string
+	StoreDB       int
+
+	// LoginCodeExpires bounds how long an emailed magic-link code stays
+	// valid before it must be used. Defaults to 15 minutes.
+	LoginCodeExpires time.Duration
+
+	// TemplateDir, if set, overrides multipass's built-in login.html,
+	// sent.html, signout.html and error.html with the files of the same
+	// names found there.
+	TemplateDir string
+	// Language selects the locale multipass's pages are rendered in, a
+	// BCP 47 tag such as "en" or "es". Defaults to English.
+	Language string
 }
 
 type Multipass struct {
@@ -43,10 +104,24 @@ type Multipass struct {
 	SiteAddr  string
 	Expires   time.Duration
 
-	sender     Sender
-	authorizer Authorizer
-	signer     jose.Signer
-	key        *rsa.PrivateKey
+	siteURL        *url.URL
+	sender         Sender
+	authorizer     Authorizer
+	keys           *KeyStore
+	authenticators *auth.Registry
+
+	cookieCipher   *session.Cipher
+	cookieSecure   bool
+	cookieSameSite http.SameSite
+	cookieDomain   string
+
+	csrfKey []byte
+
+	store            store.Store
+	revoker          store.Revoker
+	loginCodeExpires time.Duration
+
+	tmpl *templates.Set
 }
 
 func NewMultipassFromRule(r Rule) (*Multipass, error) {
@@ -80,261 +155,133 @@ func NewMultipassFromRule(r Rule) (*Multipass, error) {
 	}
 	m.authorizer = authorizer
 
-	return m, nil
-}
-
-func NewMultipass() (*Multipass, error) {
-	pk, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return nil, err
+	m.SiteAddr = r.SiteAddr
+	if r.SiteAddr != "" {
+		siteURL, err := url.Parse(r.SiteAddr)
+		if err != nil {
+			return nil, fmt.Errorf("multipass: parsing site_addr %q: %w", r.SiteAddr, err)
+		}
+		m.siteURL = siteURL
 	}
-	signer, err := jose.NewSigner(jose.PS512, pk)
-	if err != nil {
-		return nil, err
+	m.authenticators = auth.NewRegistry()
+	for _, pc := range r.Providers {
+		a, err := buildAuthenticator(context.Background(), pc, r.SiteAddr, m.Basepath)
+		if err != nil {
+			return nil, fmt.Errorf("multipass: configuring provider %q: %w", pc.Kind, err)
+		}
+		m.authenticators.Add(a)
 	}
-	return &Multipass{
-		Resources: []string{"/"},
-		Basepath:  "/",
-		Expires:   time.Hour * 24,
-		key:       pk,
-		signer:    signer,
-	}, nil
-}
 
-// Claims are part of the JSON web token
-type Claims struct {
-	Handle    string   `json:"handle"`
-	Resources []string `json:"resources"`
-	Expires   int64    `json:"exp"`
-}
-
-func (m *Multipass) AccessToken(handle string) (tokenStr string, err error) {
-	exp := time.Now().Add(m.Expires)
-	claims := &Claims{
-		Handle:    handle,
-		Resources: m.Resources,
-		Expires:   exp.Unix(),
-	}
-	payload, err := json.Marshal(claims)
-	if err != nil {
-		return "", err
+	secrets := r.CookieSecrets
+	if len(secrets) == 0 {
+		secret, err := randomState()
+		if err != nil {
+			return nil, err
+		}
+		secrets = []string{secret}
+		log.Print("multipass: no cookie_secret configured, generated an ephemeral one; sessions will not survive a restart")
 	}
-	jws, err := m.signer.Sign(payload)
+	cipher, err := session.NewCipher(secrets...)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	m.cookieCipher = cipher
+	m.cookieSecure = !r.CookieInsecure
+	m.cookieDomain = r.CookieDomain
+	m.cookieSameSite = parseSameSite(r.CookieSameSite)
 
-	return jws.CompactSerialize()
-}
-
-func (m *Multipass) LoginURL(u url.URL, tokenStr string) url.URL {
-	u.Path = path.Join(m.Basepath, "login")
-	v := url.Values{}
-	v.Set("token", tokenStr)
-	u.RawQuery = v.Encode()
-
-	return u
-}
-
-func loginHandler(w http.ResponseWriter, r *http.Request, m *Multipass) (int, error) {
-	if r.Method == "POST" {
-		r.ParseForm()
-		handle := r.PostForm.Get("handle")
-		if len(handle) == 0 {
-			loc := path.Join(m.Basepath, "login")
-			http.Redirect(w, r, loc, http.StatusSeeOther)
-			return http.StatusSeeOther, nil
-		}
-		switch m.authorizer.IsAuthorized(handle) {
-		case true:
-			token, err := m.AccessToken(handle)
-			if err != nil {
-				log.Print(err)
-			}
-			siteURL, err := url.Parse(m.SiteAddr)
-			if err != nil {
-				log.Fatal(err)
-			}
-			loginURL := m.LoginURL(*siteURL, token)
-			if err := m.sender.Send(handle, loginURL.String()); err != nil {
-				log.Print(err)
-			}
-		}
-		w.Header().Add("Content-Type", "text/html; charset=utf-8")
-		w.Write([]byte("A login link has been sent to user with handle " + handle + " if your handle is authorized"))
-		return http.StatusOK, nil
-	}
-	if r.Method == "GET" {
-		if tokenStr := r.URL.Query().Get("token"); len(tokenStr) > 0 {
-			cookie := &http.Cookie{
-				Name:  "jwt_token",
-				Value: tokenStr,
-				Path:  "/",
-			}
-			http.SetCookie(w, cookie)
-			r.URL.Path = ""
-			r.URL.RawQuery = ""
-			http.Redirect(w, r, r.URL.String(), http.StatusSeeOther)
-			return http.StatusSeeOther, nil
-		}
-		w.Header().Add("Content-Type", "text/html; charset=utf-8")
-		w.Write([]byte("<html><body><form action=" + r.URL.Path + " method=POST><input type=text name=handle /><input type=submit></form></body></html>"))
-		return http.StatusOK, nil
+	csrfSecret := r.CSRFSecret
+	if csrfSecret == "" {
+		csrfSecret = secrets[0]
 	}
-	return http.StatusMethodNotAllowed, nil
-}
-
-func loginformHandler(w http.ResponseWriter, r *http.Request, m *Multipass) (int, error) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.Write([]byte(`
-<html><body>
-<form action="` + path.Join(m.Basepath, "/login") + `" method=POST>
-<input type=hidden name=url value="` + r.URL.String() + `"/>
-<input type=text name=handle />
-<input type=submit>
-</form></body></html>
-`))
-	return http.StatusOK, nil
-}
+	csrfKey := sha256.Sum256([]byte(csrfSecret))
+	m.csrfKey = csrfKey[:]
 
-func signoutHandler(w http.ResponseWriter, r *http.Request, m *Multipass) (int, error) {
-	if cookie, err := r.Cookie("jwt_token"); err == nil {
-		cookie.Expires = time.Now().AddDate(-1, 0, 0)
-		cookie.MaxAge = -1
-		cookie.Path = "/"
-		http.SetCookie(w, cookie)
+	if r.KeyRotation > 0 {
+		m.keys.StartRotation(r.KeyRotation)
 	}
-	loc := path.Join(m.Basepath, "login")
-	http.Redirect(w, r, loc, http.StatusSeeOther)
-	return http.StatusSeeOther, nil
-}
 
-func publickeyHandler(w http.ResponseWriter, r *http.Request, m *Multipass) (int, error) {
-	data, err := x509.MarshalPKIXPublicKey(&m.key.PublicKey)
+	s, revoker, err := buildStore(r, m.store, m.revoker)
 	if err != nil {
-		return http.StatusInternalServerError, err
-	}
-	block := &pem.Block{
-		Type:  "PUBLIC KEY",
-		Bytes: data,
+		return nil, err
 	}
-	w.Header().Set("Content-Type", "application/pkix-cert")
-	if err := pem.Encode(w, block); err != nil {
-		return http.StatusInternalServerError, err
+	m.store = s
+	m.revoker = revoker
+
+	m.loginCodeExpires = 15 * time.Minute
+	if r.LoginCodeExpires > 0 {
+		m.loginCodeExpires = r.LoginCodeExpires
 	}
-	return http.StatusOK, nil
-}
 
-func tokenHandler(w http.ResponseWriter, r *http.Request, m *Multipass) (int, error) {
-	// Extract token from HTTP header, query parameter or cookie
-	tokenStr, err := extractToken(r)
+	tmpl, err := templates.New(r.TemplateDir, r.Language)
 	if err != nil {
-		return http.StatusUnauthorized, ErrInvalidToken
-	}
-	var claims *Claims
-	if claims, err = validateToken(tokenStr, m.key.PublicKey); err != nil {
-		return http.StatusUnauthorized, ErrInvalidToken
+		return nil, fmt.Errorf("multipass: loading templates: %w", err)
 	}
-	// Authorize handle claim
-	if ok := m.authorizer.IsAuthorized(claims.Handle); !ok {
-		return http.StatusUnauthorized, ErrInvalidToken
-	}
-	// Verify path claim
-	var match bool
-	for _, path := range claims.Resources {
-		if httpserver.Path(r.URL.Path).Matches(path) {
-			match = true
-			continue
-		}
-	}
-	if !match {
-		return http.StatusUnauthorized, ErrInvalidToken
-	}
-	return http.StatusOK, nil
+	m.tmpl = tmpl
+
+	return m, nil
 }
 
-func (a *Auth) ServeHTTP(w http.ResponseWriter, r *http.Request) (int, error) {
-	m := a.Multipass
-	var pathMatch bool
-	for _, path := range m.Resources {
-		if httpserver.Path(r.URL.Path).Matches(path) {
-			pathMatch = true
-			continue
+// buildStore constructs the Store/Revoker pair named by r.StoreBackend.
+// Both interfaces are implemented by the same backend value, matching
+// store.Memory, store.Bolt and store.Redis. defStore/defRevoker are the
+// Memory store NewMultipass already built, reused as-is for the "memory"
+// backend rather than building a second Memory with its own sweep
+// goroutine. For "bolt" and "redis" that default Memory is dropped, and
+// since Memory's sweep has no way to stop, its goroutine keeps running.
+func buildStore(r Rule, defStore store.Store, defRevoker store.Revoker) (store.Store, store.Revoker, error) {
+	switch r.StoreBackend {
+	case "", "memory":
+		return defStore, defRevoker, nil
+	case "bolt":
+		if r.StorePath == "" {
+			return nil, nil, errors.New("multipass: store bolt requires a store_path")
 		}
-	}
-	if !pathMatch {
-		return a.Next.ServeHTTP(w, r)
-	}
-
-	switch r.URL.Path {
-	case path.Join(m.Basepath, "pub.cer"):
-		return publickeyHandler(w, r, m)
-	case path.Join(m.Basepath, "login"):
-		return loginHandler(w, r, m)
-	case path.Join(m.Basepath, "signout"):
-		return signoutHandler(w, r, m)
-	default:
-		if code, err := tokenHandler(w, r, m); err != nil {
-			w.WriteHeader(code)
-			return loginformHandler(w, r, m)
+		b, err := store.NewBolt(r.StorePath)
+		if err != nil {
+			return nil, nil, err
 		}
-	}
-	return a.Next.ServeHTTP(w, r)
-}
-
-// extractToken returns the JWT token embedded in the given request.
-// JWT tokens can be embedded in the header prefixed with "Bearer ", with a
-// "token" key query parameter or a cookie named "jwt_token".
-func extractToken(r *http.Request) (string, error) {
-	//from header
-	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
-		if len(h) > 7 {
-			return h[7:], nil
+		return b, b, nil
+	case "redis":
+		if r.StoreAddr == "" {
+			return nil, nil, errors.New("multipass: store redis requires a store_addr")
 		}
+		rds := store.NewRedis(r.StoreAddr, r.StorePassword, r.StoreDB)
+		return rds, rds, nil
+	default:
+		return nil, nil, fmt.Errorf("multipass: unknown store backend %q", r.StoreBackend)
 	}
-
-	//from query parameter
-	if token := r.URL.Query().Get("token"); len(token) > 0 {
-		return token, nil
-	}
-
-	//from cookie
-	if cookie, err := r.Cookie("jwt_token"); err == nil {
-		return cookie.Value, nil
-	}
-
-	return "", fmt.Errorf("no token found")
 }
 
-func validateToken(token string, key rsa.PublicKey) (*Claims, error) {
-	claims := &Claims{}
-
-	// Verify token signature
-	payload, err := verifyToken(token, key)
-	if err != nil {
-		return nil, err
-	}
-	// Unmarshal token claims
-	if err := json.Unmarshal(payload, claims); err != nil {
-		return nil, err
-	}
-	// Verify expire claim
-	if time.Unix(claims.Expires, 0).Before(time.Now()) {
-		return nil, errors.New("Token expired")
+func parseSameSite(s string) http.SameSite {
+	switch strings.ToLower(s) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
 	}
-	return claims, nil
 }
 
-func verifyToken(token string, key rsa.PublicKey) ([]byte, error) {
-	var data []byte
-
-	obj, err := jose.ParseSigned(token)
+func NewMultipass() (*Multipass, error) {
+	keys, err := NewKeyStore()
 	if err != nil {
-		return data, err
+		return nil, err
 	}
-	data, err = obj.Verify(&key)
+	mem := store.NewMemory()
+	tmpl, err := templates.New("", "")
 	if err != nil {
-		return data, err
+		return nil, err
 	}
-	return data, nil
+	return &Multipass{
+		Resources:        []string{"/"},
+		Basepath:         "/",
+		Expires:          time.Hour * 24,
+		keys:             keys,
+		store:            mem,
+		revoker:          mem,
+		loginCodeExpires: 15 * time.Minute,
+		tmpl:             tmpl,
+	}, nil
 }
@@ -0,0 +1,101 @@
+package multipass
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/mholt/caddy/caddyhttp/httpserver"
+)
+
+// handlerFunc is the shape every multipass route handler has, matching the
+// (status, error) contract Caddy's httpserver.Handler expects.
+type handlerFunc func(w http.ResponseWriter, r *http.Request) (int, error)
+
+// route pairs a path pattern with the handler that serves it. A pattern
+// ending in "/" matches that path and anything nested under it (used for
+// the provider-named "login/<name>" and "callback/<name>" routes);
+// anything else must match exactly.
+type route struct {
+	pattern string
+	handler handlerFunc
+}
+
+// mux is a minimal path router over handlerFunc, standing in for
+// http.ServeMux: multipass's handlers already speak the (status, error)
+// contract Caddy expects, so routing through stdlib's http.Handler would
+// mean wrapping every handler just to unwrap it again one call later.
+type mux struct {
+	routes []route
+}
+
+func (mx *mux) handle(pattern string, h handlerFunc) {
+	mx.routes = append(mx.routes, route{pattern: pattern, handler: h})
+}
+
+// match returns the handler registered for urlPath, if any. Exact patterns
+// are tried before subtree ("/"-suffixed) ones so e.g. "basepath/login"
+// isn't shadowed by "basepath/login/".
+func (mx *mux) match(urlPath string) (handlerFunc, bool) {
+	for _, rt := range mx.routes {
+		if !strings.HasSuffix(rt.pattern, "/") && urlPath == rt.pattern {
+			return rt.handler, true
+		}
+	}
+	for _, rt := range mx.routes {
+		if strings.HasSuffix(rt.pattern, "/") && strings.HasPrefix(urlPath, rt.pattern) {
+			return rt.handler, true
+		}
+	}
+	return nil, false
+}
+
+// buildMux registers multipass's own routes under a.Basepath. Requests
+// that match a.Resources but none of these routes are authorized by
+// a.token before being handed to a.Next; requests outside a.Resources go
+// straight to a.Next.
+func (a *Auth) buildMux() {
+	mx := &mux{}
+	basepath := a.Basepath
+	mx.handle(path.Join(basepath, "pub.cer"), a.pubkey)
+	mx.handle(path.Join(basepath, ".well-known/jwks.json"), a.jwks)
+	mx.handle(path.Join(basepath, "login"), a.withCSRF(a.login))
+	mx.handle(path.Join(basepath, "login")+"/", a.providerLogin)
+	mx.handle(path.Join(basepath, "signout"), a.signout)
+	mx.handle(path.Join(basepath, "callback")+"/", a.callback)
+	a.mux = mx
+}
+
+func (a *Auth) ServeHTTP(w http.ResponseWriter, r *http.Request) (int, error) {
+	m := a.Multipass
+	if !resourceMatch(m.Resources, r.URL.Path) {
+		return a.Next.ServeHTTP(w, r)
+	}
+
+	a.once.Do(a.buildMux)
+
+	if h, ok := a.mux.match(r.URL.Path); ok {
+		return h(w, r)
+	}
+
+	return a.authorize(w, r)
+}
+
+// authorize is the fallback for any matched-resource request that isn't
+// one of multipass's own routes: it requires a valid session token,
+// falling back to the login form when one isn't present.
+func (a *Auth) authorize(w http.ResponseWriter, r *http.Request) (int, error) {
+	if _, err := a.token(w, r); err != nil {
+		return a.withCSRF(a.loginform)(w, r)
+	}
+	return a.Next.ServeHTTP(w, r)
+}
+
+func resourceMatch(resources []string, urlPath string) bool {
+	for _, p := range resources {
+		if httpserver.Path(urlPath).Matches(p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package multipass
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// mpStateCookie binds an emailed magic-link code to the browser that
+// requested it: its value is set as a high-entropy HttpOnly cookie whenever
+// the login form is rendered, hashed into the code's Store entry, and
+// re-checked against the cookie on the browser's follow-up GET, so a link
+// phished out to a different browser can't complete a session.
+const mpStateCookie = "mp_state"
+
+// issueState sets a fresh mp_state cookie, unless the request already
+// carries one, and returns the hash to bind into the login code.
+func issueState(w http.ResponseWriter, r *http.Request, basepath string) (string, error) {
+	if c, err := r.Cookie(mpStateCookie); err == nil && c.Value != "" {
+		return hashState(c.Value), nil
+	}
+	state, err := randomState()
+	if err != nil {
+		return "", err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     mpStateCookie,
+		Value:    state,
+		Path:     basepath,
+		HttpOnly: true,
+		MaxAge:   int((30 * time.Minute).Seconds()),
+	})
+	return hashState(state), nil
+}
+
+// requestState hashes the mp_state cookie on r, or "" if it has none.
+func requestState(r *http.Request) string {
+	c, err := r.Cookie(mpStateCookie)
+	if err != nil {
+		return ""
+	}
+	return hashState(c.Value)
+}
+
+func hashState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
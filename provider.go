@@ -0,0 +1,250 @@
+package multipass
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/mholt/caddy/caddyfile"
+
+	"github.com/dbnegative/multipass/auth"
+)
+
+// ProviderConfig is the parsed form of a Caddyfile `provider` block, e.g.
+//
+//	provider oidc {
+//		issuer        https://accounts.example.com
+//		client_id     abc123
+//		client_secret shh
+//		scopes        openid email
+//	}
+type ProviderConfig struct {
+	Kind         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	Domain       string
+	Org          string
+}
+
+// ParseProviderDirective parses a single `provider <kind> { ... }` block
+// from a Caddyfile dispenser positioned on the `provider` token.
+func ParseProviderDirective(d *caddyfile.Dispenser) (ProviderConfig, error) {
+	pc := ProviderConfig{}
+	if !d.NextArg() {
+		return pc, d.ArgErr()
+	}
+	pc.Kind = d.Val()
+
+	for d.NextBlock() {
+		switch d.Val() {
+		case "issuer":
+			if !d.NextArg() {
+				return pc, d.ArgErr()
+			}
+			pc.Issuer = d.Val()
+		case "client_id":
+			if !d.NextArg() {
+				return pc, d.ArgErr()
+			}
+			pc.ClientID = d.Val()
+		case "client_secret":
+			if !d.NextArg() {
+				return pc, d.ArgErr()
+			}
+			pc.ClientSecret = d.Val()
+		case "scopes":
+			pc.Scopes = d.RemainingArgs()
+		case "domain":
+			if !d.NextArg() {
+				return pc, d.ArgErr()
+			}
+			pc.Domain = d.Val()
+		case "org":
+			if !d.NextArg() {
+				return pc, d.ArgErr()
+			}
+			pc.Org = d.Val()
+		default:
+			return pc, d.ArgErr()
+		}
+	}
+	return pc, nil
+}
+
+// buildAuthenticator turns a ProviderConfig into the concrete auth.Authenticator
+// it names, wiring up the callback URL under the rule's basepath.
+func buildAuthenticator(ctx context.Context, pc ProviderConfig, siteAddr, basepath string) (auth.Authenticator, error) {
+	redirectURL := siteAddr + path.Join(basepath, "callback", pc.Kind)
+
+	switch pc.Kind {
+	case "google":
+		return auth.NewGoogle(ctx, auth.GoogleConfig{
+			ClientID:     pc.ClientID,
+			ClientSecret: pc.ClientSecret,
+			RedirectURL:  redirectURL,
+			Domain:       pc.Domain,
+		})
+	case "github":
+		return auth.NewGithub(auth.GithubConfig{
+			ClientID:     pc.ClientID,
+			ClientSecret: pc.ClientSecret,
+			RedirectURL:  redirectURL,
+			Org:          pc.Org,
+		}), nil
+	case "oidc":
+		return auth.NewOIDC(ctx, auth.OIDCConfig{
+			Name:         "oidc",
+			Issuer:       pc.Issuer,
+			ClientID:     pc.ClientID,
+			ClientSecret: pc.ClientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       pc.Scopes,
+		})
+	default:
+		return nil, fmt.Errorf("multipass: unknown provider %q", pc.Kind)
+	}
+}
+
+// ParseStoreDirective parses a
+//
+//	store <backend> {
+//		path  ./multipass.db
+//		addr  localhost:6379
+//		password ...
+//		db    0
+//	}
+//
+// directive selecting the backend that holds single-use login codes and
+// revoked jtis.
+func ParseStoreDirective(d *caddyfile.Dispenser, r *Rule) error {
+	if !d.NextArg() {
+		return d.ArgErr()
+	}
+	r.StoreBackend = d.Val()
+
+	for d.NextBlock() {
+		switch d.Val() {
+		case "path":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			r.StorePath = d.Val()
+		case "addr":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			r.StoreAddr = d.Val()
+		case "password":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			r.StorePassword = d.Val()
+		case "db":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			n, err := strconv.Atoi(d.Val())
+			if err != nil {
+				return d.Err(err.Error())
+			}
+			r.StoreDB = n
+		default:
+			return d.ArgErr()
+		}
+	}
+	return nil
+}
+
+// ParseKeyRotationDirective parses a `key_rotation <duration>` directive
+// line from a Caddyfile dispenser positioned on the directive token.
+func ParseKeyRotationDirective(d *caddyfile.Dispenser, r *Rule) error {
+	if !d.NextArg() {
+		return d.ArgErr()
+	}
+	dur, err := time.ParseDuration(d.Val())
+	if err != nil {
+		return d.Err(err.Error())
+	}
+	r.KeyRotation = dur
+	return nil
+}
+
+// ParseCookieDirective parses a `cookie_secret`, `cookie_secure`,
+// `cookie_samesite` or `cookie_domain` directive line from a Caddyfile
+// dispenser positioned on the directive token, applying it to r.
+// `cookie_secret` accepts one or more whitespace-separated values, the
+// oldest last, to support key rotation.
+func ParseCookieDirective(d *caddyfile.Dispenser, r *Rule) error {
+	switch d.Val() {
+	case "cookie_secret":
+		args := d.RemainingArgs()
+		if len(args) == 0 {
+			return d.ArgErr()
+		}
+		r.CookieSecrets = args
+	case "cookie_secure":
+		if !d.NextArg() {
+			return d.ArgErr()
+		}
+		r.CookieInsecure = d.Val() == "false"
+	case "cookie_samesite":
+		if !d.NextArg() {
+			return d.ArgErr()
+		}
+		r.CookieSameSite = d.Val()
+	case "cookie_domain":
+		if !d.NextArg() {
+			return d.ArgErr()
+		}
+		r.CookieDomain = d.Val()
+	default:
+		return d.ArgErr()
+	}
+	return nil
+}
+
+// ParseCSRFDirective parses a `csrf_secret <secret>` directive line from a
+// Caddyfile dispenser positioned on the directive token.
+func ParseCSRFDirective(d *caddyfile.Dispenser, r *Rule) error {
+	if !d.NextArg() {
+		return d.ArgErr()
+	}
+	r.CSRFSecret = d.Val()
+	return nil
+}
+
+// ParseTemplateDirective parses a `template_dir <path>` directive line from
+// a Caddyfile dispenser positioned on the directive token.
+func ParseTemplateDirective(d *caddyfile.Dispenser, r *Rule) error {
+	if !d.NextArg() {
+		return d.ArgErr()
+	}
+	r.TemplateDir = d.Val()
+	return nil
+}
+
+// ParseLanguageDirective parses a `language <tag>` directive line from a
+// Caddyfile dispenser positioned on the directive token.
+func ParseLanguageDirective(d *caddyfile.Dispenser, r *Rule) error {
+	if !d.NextArg() {
+		return d.ArgErr()
+	}
+	r.Language = d.Val()
+	return nil
+}
+
+// randomState returns a URL-safe random value suitable for binding an
+// outgoing OAuth2 redirect to the callback that completes it.
+func randomState() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
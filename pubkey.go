@@ -0,0 +1,26 @@
+package multipass
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+)
+
+// pubkey serves the current signing key as a PEM-encoded PKIX public key,
+// kept for verifiers that haven't moved to the JWKS endpoint.
+func (a *Auth) pubkey(w http.ResponseWriter, r *http.Request) (int, error) {
+	m := a.Multipass
+	data, err := x509.MarshalPKIXPublicKey(m.keys.CurrentPublicKey())
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	block := &pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: data,
+	}
+	w.Header().Set("Content-Type", "application/pkix-cert")
+	if err := pem.Encode(w, block); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,62 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxCookieSize is the largest value a single cookie chunk is allowed to
+// carry. Encrypted, signed session values routinely exceed the ~4KB most
+// browsers cap a single cookie at, so values over this size are split
+// across numbered chunks (name_0, name_1, ...) and reassembled on read.
+const MaxCookieSize = 4000
+
+// Split divides value into ordered (name, chunk) pairs no larger than
+// MaxCookieSize. A value that already fits returns a single pair using the
+// bare name, matching the pre-chunking cookie format.
+func Split(name, value string) []Chunk {
+	if len(value) <= MaxCookieSize {
+		return []Chunk{{Name: name, Value: value}}
+	}
+	var chunks []Chunk
+	for i := 0; i*MaxCookieSize < len(value); i++ {
+		start := i * MaxCookieSize
+		end := start + MaxCookieSize
+		if end > len(value) {
+			end = len(value)
+		}
+		chunks = append(chunks, Chunk{
+			Name:  fmt.Sprintf("%s_%d", name, i),
+			Value: value[start:end],
+		})
+	}
+	return chunks
+}
+
+// Chunk is one cookie produced by Split.
+type Chunk struct {
+	Name  string
+	Value string
+}
+
+// Join reassembles a value previously split with Split. lookup returns a
+// cookie's raw value given its name, matching http.Request.Cookie. Join
+// first tries the bare name (the unchunked case), then walks name_0,
+// name_1, ... until a chunk is missing.
+func Join(name string, lookup func(name string) (string, bool)) (string, bool) {
+	if v, ok := lookup(name); ok {
+		return v, true
+	}
+	var b strings.Builder
+	for i := 0; ; i++ {
+		v, ok := lookup(fmt.Sprintf("%s_%d", name, i))
+		if !ok {
+			break
+		}
+		b.WriteString(v)
+	}
+	if b.Len() == 0 {
+		return "", false
+	}
+	return b.String(), true
+}
@@ -0,0 +1,148 @@
+// Package session seals and opens the value multipass's session cookie
+// carries. Cipher does the sealing (encrypt-then-MAC, with a timestamp so
+// a stolen cookie can't outlive its own TTL); Split and Join in chunk.go
+// spread a sealed value too large for one cookie across several.
+//
+// The cookie's payload today is just the signed Multipass JWT (see
+// AccessToken in the root package). Carrying a provider's access/refresh
+// tokens alongside it, so a future handler could refresh a federated
+// session without a reauth, was considered and deliberately deferred:
+// nothing in this tree consumes a refresh token yet, and serializing one
+// into the cookie ahead of that handler existing would just be unused
+// surface area. Revisit this package's shape if/when that handler lands.
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCookie is returned when a cookie value fails to verify, either
+// because its signature doesn't match or it has aged past TTL.
+var ErrInvalidCookie = errors.New("session: invalid cookie")
+
+// Cipher encrypts and HMAC-signs cookie values the way oauth2_proxy does:
+// the value is AES-CFB encrypted under a key derived from a seed (the
+// seed's SHA-256 digest), then the cookie carries
+// "value|timestamp|signature" where signature is
+// HMAC-SHA256(key, name|value|timestamp) under that same key. The timestamp gives a
+// server-side TTL independent of whatever expiry is inside the value
+// itself (e.g. a JWT's own exp claim).
+//
+// Cipher holds an ordered list of seeds so a secret can be rotated: the
+// first seed signs and encrypts new cookies, but Open tries every seed in
+// order, so cookies signed under a previous secret keep verifying until
+// operators drop it from the list.
+type Cipher struct {
+	keys [][]byte
+}
+
+// NewCipher derives AES-256/HMAC keys from seeds. seeds[0] is the current
+// secret; any remaining entries are previous secrets kept only so cookies
+// signed under them still verify.
+func NewCipher(seeds ...string) (*Cipher, error) {
+	if len(seeds) == 0 {
+		return nil, errors.New("session: at least one cookie secret is required")
+	}
+	c := &Cipher{}
+	for _, s := range seeds {
+		key := sha256.Sum256([]byte(s))
+		c.keys = append(c.keys, key[:])
+	}
+	return c, nil
+}
+
+// Seal encrypts and signs value under name, returning the string to store
+// as the cookie's value.
+func (c *Cipher) Seal(name string, value []byte, now time.Time) (string, error) {
+	ciphertext, err := encrypt(c.keys[0], value)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.URLEncoding.EncodeToString(ciphertext)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sig := sign(c.keys[0], name, encoded, ts)
+	return strings.Join([]string{encoded, ts, sig}, "|"), nil
+}
+
+// Open verifies and decrypts a cookie value produced by Seal, rejecting it
+// once it is older than maxAge. It is the caller's job to additionally
+// check any expiry embedded in the decrypted value.
+func (c *Cipher) Open(name, cookie string, maxAge time.Duration, now time.Time) ([]byte, error) {
+	parts := strings.SplitN(cookie, "|", 3)
+	if len(parts) != 3 {
+		return nil, ErrInvalidCookie
+	}
+	encoded, ts, sig := parts[0], parts[1], parts[2]
+
+	var key []byte
+	for _, k := range c.keys {
+		if hmac.Equal([]byte(sign(k, name, encoded, ts)), []byte(sig)) {
+			key = k
+			break
+		}
+	}
+	if key == nil {
+		return nil, ErrInvalidCookie
+	}
+
+	issued, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCookie
+	}
+	if maxAge > 0 && now.After(time.Unix(issued, 0).Add(maxAge)) {
+		return nil, ErrInvalidCookie
+	}
+
+	ciphertext, err := base64.URLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCookie
+	}
+	return decrypt(key, ciphertext)
+}
+
+func sign(key []byte, name, value, timestamp string) string {
+	h := hmac.New(sha256.New, key)
+	fmt.Fprintf(h, "%s|%s|%s", name, value, timestamp)
+	return base64.URLEncoding.EncodeToString(h.Sum(nil))
+}
+
+func encrypt(key, value []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key[:32])
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := make([]byte, aes.BlockSize+len(value))
+	iv := ciphertext[:aes.BlockSize]
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, err
+	}
+	stream := cipher.NewCFBEncrypter(block, iv)
+	stream.XORKeyStream(ciphertext[aes.BlockSize:], value)
+	return ciphertext, nil
+}
+
+func decrypt(key, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key[:32])
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aes.BlockSize {
+		return nil, ErrInvalidCookie
+	}
+	iv := ciphertext[:aes.BlockSize]
+	out := make([]byte, len(ciphertext)-aes.BlockSize)
+	stream := cipher.NewCFBDecrypter(block, iv)
+	stream.XORKeyStream(out, ciphertext[aes.BlockSize:])
+	return out, nil
+}
@@ -0,0 +1,29 @@
+package multipass
+
+import (
+	"log"
+	"net/http"
+	"path"
+	"time"
+)
+
+// signout revokes the current session's jti server-side, so it can't be
+// replayed even though its exp claim hasn't passed yet, then clears the
+// session cookie.
+func (a *Auth) signout(w http.ResponseWriter, r *http.Request) (int, error) {
+	m := a.Multipass
+	if tokenStr, err := m.sessionToken(r); err == nil {
+		if claims, err := validateToken(tokenStr, m.keys); err == nil {
+			if err := m.revoker.Revoke(claims.ID, time.Unix(claims.Expires, 0)); err != nil {
+				log.Print(err)
+			}
+		}
+	}
+	m.clearSessionCookie(w, r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	data := struct{ LoginURL string }{LoginURL: path.Join(m.Basepath, "login")}
+	if err := m.tmpl.Execute(w, "signout.html", data); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+var (
+	codesBucket   = []byte("codes")
+	revokedBucket = []byte("revoked")
+)
+
+// Bolt is a Store and Revoker backed by a local BoltDB file, for operators
+// who want login codes and revocations to survive a restart without
+// running a separate cache service.
+type Bolt struct {
+	db   *bolt.DB
+	done chan struct{}
+}
+
+type boltEntry struct {
+	Handle string    `json:"handle"`
+	State  string    `json:"state"`
+	Exp    time.Time `json:"exp"`
+}
+
+// NewBolt opens (creating if necessary) a BoltDB file at path and starts a
+// background sweep that drops expired codes and revocations, since unlike
+// Memory's maps a BoltDB bucket has no built-in TTL and would otherwise
+// grow for as long as the file exists.
+func NewBolt(path string) (*Bolt, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(codesBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(revokedBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	b := &Bolt{db: db, done: make(chan struct{})}
+	go b.sweep()
+	return b, nil
+}
+
+func (b *Bolt) sweep() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			b.db.Update(func(tx *bolt.Tx) error {
+				deleteExpired(tx.Bucket(codesBucket), now, func(data []byte) (time.Time, error) {
+					var entry boltEntry
+					err := json.Unmarshal(data, &entry)
+					return entry.Exp, err
+				})
+				deleteExpired(tx.Bucket(revokedBucket), now, func(data []byte) (time.Time, error) {
+					var exp time.Time
+					err := json.Unmarshal(data, &exp)
+					return exp, err
+				})
+				return nil
+			})
+		case <-b.done:
+			return
+		}
+	}
+}
+
+// deleteExpired removes every key in bkt whose value, decoded by exp,
+// names a time before now.
+func deleteExpired(bkt *bolt.Bucket, now time.Time, exp func(data []byte) (time.Time, error)) error {
+	c := bkt.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		t, err := exp(v)
+		if err != nil {
+			continue
+		}
+		if now.After(t) {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close stops the sweep and releases the underlying BoltDB file.
+func (b *Bolt) Close() error {
+	close(b.done)
+	return b.db.Close()
+}
+
+// Put implements Store.
+func (b *Bolt) Put(code, handle, state string, exp time.Time) error {
+	data, err := json.Marshal(boltEntry{Handle: handle, State: state, Exp: exp})
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(codesBucket).Put([]byte(code), data)
+	})
+}
+
+// Consume implements Store.
+func (b *Bolt) Consume(code, state string) (string, bool, error) {
+	var entry boltEntry
+	found := false
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(codesBucket)
+		data := bkt.Get([]byte(code))
+		if data == nil {
+			return nil
+		}
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return err
+		}
+		found = true
+		return bkt.Delete([]byte(code))
+	})
+	if err != nil {
+		return "", false, err
+	}
+	if !found || time.Now().After(entry.Exp) || entry.State != state {
+		return "", false, nil
+	}
+	return entry.Handle, true, nil
+}
+
+// Revoke implements Revoker.
+func (b *Bolt) Revoke(jti string, exp time.Time) error {
+	data, err := json.Marshal(exp)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(revokedBucket).Put([]byte(jti), data)
+	})
+}
+
+// Revoked implements Revoker.
+func (b *Bolt) Revoked(jti string) (bool, error) {
+	var exp time.Time
+	found := false
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(revokedBucket).Get([]byte(jti))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &exp)
+	})
+	if err != nil || !found {
+		return false, err
+	}
+	return time.Now().Before(exp), nil
+}
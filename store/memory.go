@@ -0,0 +1,96 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// Memory is an in-process Store and Revoker. It does not share state across
+// multipass instances, so it is only suitable for a single-process
+// deployment; use Bolt or Redis behind multiple Caddy instances.
+type Memory struct {
+	mu      sync.Mutex
+	codes   map[string]memoryEntry
+	revoked map[string]time.Time
+}
+
+type memoryEntry struct {
+	handle string
+	state  string
+	exp    time.Time
+}
+
+// NewMemory returns an empty Memory store and starts a background sweep
+// that drops expired codes and revocations so the maps don't grow forever.
+func NewMemory() *Memory {
+	m := &Memory{
+		codes:   map[string]memoryEntry{},
+		revoked: map[string]time.Time{},
+	}
+	go m.sweep()
+	return m
+}
+
+func (m *Memory) sweep() {
+	for range time.Tick(time.Minute) {
+		now := time.Now()
+		m.mu.Lock()
+		for code, e := range m.codes {
+			if now.After(e.exp) {
+				delete(m.codes, code)
+			}
+		}
+		for jti, exp := range m.revoked {
+			if now.After(exp) {
+				delete(m.revoked, jti)
+			}
+		}
+		m.mu.Unlock()
+	}
+}
+
+// Put implements Store.
+func (m *Memory) Put(code, handle, state string, exp time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.codes[code] = memoryEntry{handle: handle, state: state, exp: exp}
+	return nil
+}
+
+// Consume implements Store.
+func (m *Memory) Consume(code, state string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.codes[code]
+	if !ok {
+		return "", false, nil
+	}
+	delete(m.codes, code)
+	if time.Now().After(e.exp) || e.state != state {
+		return "", false, nil
+	}
+	return e.handle, true, nil
+}
+
+// Revoke implements Revoker.
+func (m *Memory) Revoke(jti string, exp time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revoked[jti] = exp
+	return nil
+}
+
+// Revoked implements Revoker.
+func (m *Memory) Revoked(jti string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	exp, ok := m.revoked[jti]
+	if !ok {
+		return false, nil
+	}
+	if time.Now().After(exp) {
+		delete(m.revoked, jti)
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const revokedKeyPrefix = "multipass:revoked:"
+
+type redisEntry struct {
+	Handle string `json:"handle"`
+	State  string `json:"state"`
+}
+
+// consumeScript atomically reads and deletes a code, the v6 client's
+// substitute for GetDel (added in v8): without it, a GET followed by a DEL
+// would let two concurrent requests both read the code before either
+// deletes it.
+var consumeScript = redis.NewScript(`
+local v = redis.call("GET", KEYS[1])
+if v then
+	redis.call("DEL", KEYS[1])
+end
+return v
+`)
+
+// Redis is a Store and Revoker backed by a Redis instance, so multipass can
+// run behind multiple Caddy instances sharing one place to consume login
+// codes and check revocations.
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis returns a Redis store talking to addr (host:port).
+func NewRedis(addr, password string, db int) *Redis {
+	return &Redis{client: redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})}
+}
+
+// Put implements Store, expiring the code itself so it never needs
+// separate cleanup. A non-positive TTL is a no-op: passing it to Set would
+// mean "no expiration" to Redis, storing a code that's already expired
+// forever.
+func (r *Redis) Put(code, handle, state string, exp time.Time) error {
+	ttl := time.Until(exp)
+	if ttl <= 0 {
+		return nil
+	}
+	data, err := json.Marshal(redisEntry{Handle: handle, State: state})
+	if err != nil {
+		return err
+	}
+	return r.client.Set(code, data, ttl).Err()
+}
+
+// Consume implements Store. consumeScript runs the read and delete as one
+// atomic Lua call, so a code can't be read by two concurrent requests and
+// used twice.
+func (r *Redis) Consume(code, state string) (string, bool, error) {
+	res, err := consumeScript.Run(r.client, []string{code}).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	data, ok := res.(string)
+	if !ok {
+		return "", false, nil
+	}
+	var entry redisEntry
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		return "", false, err
+	}
+	if entry.State != state {
+		return "", false, nil
+	}
+	return entry.Handle, true, nil
+}
+
+// Revoke implements Revoker. A non-positive TTL is a no-op, for the same
+// reason as Put: it would otherwise persist the revocation forever.
+func (r *Redis) Revoke(jti string, exp time.Time) error {
+	ttl := time.Until(exp)
+	if ttl <= 0 {
+		return nil
+	}
+	return r.client.Set(revokedKeyPrefix+jti, "1", ttl).Err()
+}
+
+// Revoked implements Revoker.
+func (r *Redis) Revoked(jti string) (bool, error) {
+	n, err := r.client.Exists(revokedKeyPrefix + jti).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
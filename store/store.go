@@ -0,0 +1,33 @@
+// Package store backs the lifecycle of single-use magic-link login codes
+// and revoked session JWTs, with implementations that work whether
+// multipass runs as one process or behind several Caddy instances sharing
+// state.
+package store
+
+import "time"
+
+// Store holds single-use magic-link login codes. A code is written once by
+// the handler that emails the link, and Consume must atomically read and
+// delete it so the same emailed link cannot be used twice.
+type Store interface {
+	// Put records that code authenticates handle until exp, bound to
+	// state (a hash of the mp_state cookie the browser held when the
+	// login form was shown) so Consume can refuse a code presented by a
+	// different browser than the one that requested it.
+	Put(code, handle, state string, exp time.Time) error
+	// Consume looks up code, deleting it so it cannot be used again. ok
+	// is false if the code was never issued, already consumed, expired,
+	// or state doesn't match the value it was Put with.
+	Consume(code, state string) (handle string, ok bool, err error)
+}
+
+// Revoker records JWT IDs (the "jti" claim) that have been signed out of
+// early, so a token can be rejected before its own exp claim would
+// otherwise expire it.
+type Revoker interface {
+	// Revoke marks jti as invalid until exp, matching the token's own expiry
+	// so the revocation record doesn't need to be kept any longer than that.
+	Revoke(jti string, exp time.Time) error
+	// Revoked reports whether jti has been revoked.
+	Revoked(jti string) (bool, error)
+}
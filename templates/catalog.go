@@ -0,0 +1,24 @@
+package templates
+
+import (
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// init registers example non-English translations for the default
+// templates' {{T}} calls. Operators adding a language only need more
+// message.SetString calls, not a fork of the templates themselves.
+func init() {
+	message.SetString(language.Spanish, "Handle", "Usuario")
+	message.SetString(language.Spanish, "Log in", "Iniciar sesión")
+	message.SetString(language.Spanish,
+		"A login link has been sent to %s if your handle is authorized.",
+		"Se ha enviado un enlace de acceso a %s si tu usuario está autorizado.")
+	message.SetString(language.Spanish, "You have been signed out.", "Tu sesión se cerró.")
+	message.SetString(language.Spanish,
+		"This login link is invalid, expired, already used, or was not opened in the browser it was requested from.",
+		"Este enlace de acceso no es válido, expiró, ya se usó, o no se abrió en el mismo navegador donde se solicitó.")
+	message.SetString(language.Spanish,
+		"Your form session has expired. Please reload the page and try again.",
+		"Tu sesión de formulario expiró. Recarga la página e inténtalo de nuevo.")
+}
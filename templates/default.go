@@ -0,0 +1,37 @@
+package templates
+
+// defaultTemplates holds multipass's built-in pages, each its own
+// {{define}} block named after the page. A Rule.TemplateDir override
+// replaces this whole set rather than merging with it, so it must supply
+// all four pages.
+const defaultTemplates = `
+{{define "login.html"}}
+<html><body>
+<form action="{{.FormAction}}" method=POST>
+{{.CSRFField}}
+{{if .ReturnURL}}<input type=hidden name=url value="{{.ReturnURL}}"/>{{end}}
+<input type=text name=handle placeholder="{{T "Handle"}}" />
+<input type=submit value="{{T "Log in"}}" />
+</form>
+</body></html>
+{{end}}
+
+{{define "sent.html"}}
+<html><body>
+<p>{{T "A login link has been sent to %s if your handle is authorized." .Handle}}</p>
+</body></html>
+{{end}}
+
+{{define "signout.html"}}
+<html><body>
+<p>{{T "You have been signed out."}}</p>
+<p><a href="{{.LoginURL}}">{{T "Log in"}}</a></p>
+</body></html>
+{{end}}
+
+{{define "error.html"}}
+<html><body>
+<p>{{.Message}}</p>
+</body></html>
+{{end}}
+`
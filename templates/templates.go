@@ -0,0 +1,61 @@
+// Package templates renders multipass's user-facing pages from named
+// html/template definitions, with localized text via golang.org/x/text/message
+// so operators can override pages per-deployment (Rule.TemplateDir) and
+// serve them in a language other than English (Rule.Language).
+package templates
+
+import (
+	"html/template"
+	"io"
+	"path/filepath"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Set is the named collection of pages multipass renders: login.html (the
+// handle-entry form), sent.html ("check your email"), signout.html and
+// error.html. A TemplateDir override is expected to define the same four
+// names so Execute keeps working regardless of where they were parsed from.
+type Set struct {
+	tmpl    *template.Template
+	printer *message.Printer
+}
+
+// New builds a Set from the built-in templates, or from the *.html files in
+// dir if dir is non-empty, with their {{T}} calls localized to lang (a BCP
+// 47 tag such as "en" or "es"; empty or unrecognized defaults to English).
+func New(dir, lang string) (*Set, error) {
+	tag := language.English
+	if lang != "" {
+		if t, err := language.Parse(lang); err == nil {
+			tag = t
+		}
+	}
+	printer := message.NewPrinter(tag)
+	funcs := template.FuncMap{"T": printer.Sprintf}
+
+	var tmpl *template.Template
+	var err error
+	if dir == "" {
+		tmpl, err = template.New("").Funcs(funcs).Parse(defaultTemplates)
+	} else {
+		tmpl, err = template.New("").Funcs(funcs).ParseGlob(filepath.Join(dir, "*.html"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Set{tmpl: tmpl, printer: printer}, nil
+}
+
+// Execute renders the named template (e.g. "login.html") with data to w.
+func (s *Set) Execute(w io.Writer, name string, data interface{}) error {
+	return s.tmpl.ExecuteTemplate(w, name, data)
+}
+
+// T returns the localized message for key, the same lookup {{T}} does
+// inside a template, for callers that need translated text outside one
+// (e.g. building an error.html Message field).
+func (s *Set) T(key string, args ...interface{}) string {
+	return s.printer.Sprintf(key, args...)
+}
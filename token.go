@@ -0,0 +1,145 @@
+package multipass
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mholt/caddy/caddyhttp/httpserver"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// Claims are part of the JSON web token.
+type Claims struct {
+	Handle    string   `json:"handle"`
+	Resources []string `json:"resources"`
+	Expires   int64    `json:"exp"`
+	ID        string   `json:"jti"`
+}
+
+// AccessToken mints a signed session JWT for handle.
+func (m *Multipass) AccessToken(handle string) (tokenStr string, err error) {
+	exp := time.Now().Add(m.Expires)
+	jti, err := randomState()
+	if err != nil {
+		return "", err
+	}
+	claims := &Claims{
+		Handle:    handle,
+		Resources: m.Resources,
+		Expires:   exp.Unix(),
+		ID:        jti,
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	_, signer := m.keys.Current()
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		return "", err
+	}
+
+	return jws.CompactSerialize()
+}
+
+// token is the auth gate applied to any request matching m.Resources that
+// isn't one of multipass's own routes: it extracts, verifies and
+// authorizes the session token, and checks the requested path against the
+// token's resources claim.
+func (a *Auth) token(w http.ResponseWriter, r *http.Request) (int, error) {
+	m := a.Multipass
+	tokenStr, err := extractToken(r, m)
+	if err != nil {
+		return http.StatusUnauthorized, ErrInvalidToken
+	}
+	claims, err := validateToken(tokenStr, m.keys)
+	if err != nil {
+		return http.StatusUnauthorized, ErrInvalidToken
+	}
+	// Reject sessions signed out server-side before their exp claim
+	if revoked, err := m.revoker.Revoked(claims.ID); err != nil || revoked {
+		return http.StatusUnauthorized, ErrInvalidToken
+	}
+	// Authorize handle claim
+	if ok := m.authorizer.IsAuthorized(claims.Handle); !ok {
+		return http.StatusUnauthorized, ErrInvalidToken
+	}
+	// Verify path claim
+	var match bool
+	for _, p := range claims.Resources {
+		if httpserver.Path(r.URL.Path).Matches(p) {
+			match = true
+			continue
+		}
+	}
+	if !match {
+		return http.StatusUnauthorized, ErrInvalidToken
+	}
+	return http.StatusOK, nil
+}
+
+// extractToken returns the JWT token embedded in the given request.
+// JWT tokens can be embedded in the header prefixed with "Bearer ", with a
+// "token" key query parameter or the (possibly chunked) session cookie.
+func extractToken(r *http.Request, m *Multipass) (string, error) {
+	//from header
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		if len(h) > 7 {
+			return h[7:], nil
+		}
+	}
+
+	//from query parameter
+	if token := r.URL.Query().Get("token"); len(token) > 0 {
+		return token, nil
+	}
+
+	//from cookie
+	if token, err := m.sessionToken(r); err == nil {
+		return token, nil
+	}
+
+	return "", fmt.Errorf("no token found")
+}
+
+func validateToken(token string, keys *KeyStore) (*Claims, error) {
+	claims := &Claims{}
+
+	// Verify token signature
+	payload, err := verifyToken(token, keys)
+	if err != nil {
+		return nil, err
+	}
+	// Unmarshal token claims
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, err
+	}
+	// Verify expire claim
+	if time.Unix(claims.Expires, 0).Before(time.Now()) {
+		return nil, errors.New("Token expired")
+	}
+	return claims, nil
+}
+
+// verifyToken checks the JWS signature on token, picking the verifying key
+// by the "kid" its protected header names so rotation doesn't invalidate
+// tokens signed under a previous key.
+func verifyToken(token string, keys *KeyStore) ([]byte, error) {
+	obj, err := jose.ParseSigned(token)
+	if err != nil {
+		return nil, err
+	}
+	if len(obj.Signatures) == 0 {
+		return nil, errors.New("token has no signature")
+	}
+	kid := obj.Signatures[0].Header.KeyID
+	key, ok := keys.PublicKey(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return obj.Verify(key)
+}